@@ -0,0 +1,54 @@
+package gateway
+
+import "sync"
+
+// ConnectorFactory builds a Connector for gateway, given the connector server
+// address (broker URL, listen address, etc., depending on the transport).
+type ConnectorFactory func(gateway *Gateway, connectorServer string) (Connector, error)
+
+var (
+	connectorFactoriesMu sync.RWMutex
+	connectorFactories   = make(map[int]ConnectorFactory)
+)
+
+// RegisterConnector registers the factory used to build a Connector for mode,
+// overriding any factory previously registered for the same mode. GatewayModeMqtt,
+// GatewayModeNats, GatewayModeHttpPull and GatewayModeHttpPush are registered by
+// default; call RegisterConnector to plug in additional transports (AMQP, WebSocket, ...).
+func RegisterConnector(mode int, factory ConnectorFactory) {
+	connectorFactoriesMu.Lock()
+	defer connectorFactoriesMu.Unlock()
+	connectorFactories[mode] = factory
+}
+
+func lookupConnector(mode int) (ConnectorFactory, bool) {
+	connectorFactoriesMu.RLock()
+	defer connectorFactoriesMu.RUnlock()
+	factory, found := connectorFactories[mode]
+	return factory, found
+}
+
+func init() {
+	RegisterConnector(GatewayModeMqtt, func(gateway *Gateway, connectorServer string) (Connector, error) {
+		var mqttOptions *MqttOptions
+		if opts, found := gateway.opts["mqttOptions"]; found {
+			mqttOptions = opts.(*MqttOptions)
+		}
+		return NewMqttConnector(gateway, connectorServer, mqttOptions)
+	})
+	RegisterConnector(GatewayModeNats, func(gateway *Gateway, connectorServer string) (Connector, error) {
+		var natsOptions *NatsOptions
+		if opts, found := gateway.opts["natsOptions"]; found {
+			natsOptions = opts.(*NatsOptions)
+		}
+		return NewNatsConnector(gateway, connectorServer, natsOptions)
+	})
+	RegisterConnector(GatewayModeHttpPull, NewHttpPullConnector)
+	RegisterConnector(GatewayModeHttpPush, func(gateway *Gateway, connectorServer string) (Connector, error) {
+		var httpPushOptions *HttpPushOptions
+		if opts, found := gateway.opts["httpPushOptions"]; found {
+			httpPushOptions = opts.(*HttpPushOptions)
+		}
+		return NewHttpPushConnector(gateway, connectorServer, httpPushOptions)
+	})
+}
@@ -2,13 +2,13 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -26,7 +26,7 @@ func init() {
 type Connector interface {
 	Start() error
 	Stop() error
-	Publish(data map[string]interface{}) error
+	Publish(ctx context.Context, data map[string]interface{}) error
 }
 
 // Gateway represents gateway service
@@ -40,12 +40,34 @@ type Gateway struct {
 	Account      string             `json:"owner,omitempty"`
 	Mode         int                `json:"mode"`
 	Things       map[string]wrapper `json:"sensors,omitempty"`
-	Logger       *log.Logger
+	Logger       Logger
 	connector    Connector
 	deviotServer string
 	registered   int32
+	opts         map[string]interface{}
+	thingsMu     sync.RWMutex
+
+	registerInterval time.Duration
+	httpClient       *http.Client
+	registerCancel   context.CancelFunc
+	readyCh          chan struct{}
+	readyOnce        sync.Once
+
+	// OnRegister is called after a thing has been registered.
+	OnRegister func(id string, thing Thing)
+	// OnDeregister is called after a thing has been deregistered.
+	OnDeregister func(id string, thing Thing)
+	// OnAction is called before an action is dispatched to a registered thing.
+	OnAction func(id string, action string, data map[string]interface{})
+	// OnRegistered is called the first time the gateway successfully registers
+	// with the DevIoT server, and again on every reconnect. It's a good place
+	// to re-push cached thing state after a gap in connectivity.
+	OnRegistered func()
 }
 
+const defaultRegisterInterval = 1 * time.Minute
+const defaultHTTPTimeout = 30 * time.Second
+
 // GatewayModeHttpPull connect to DevIoT using pull based HTTP protocol
 const GatewayModeHttpPull = 0
 
@@ -55,31 +77,66 @@ const GatewayModeHttpPush = 1
 // GatewayModeMqtt connect to DevIoT using MQTT protocol
 const GatewayModeMqtt = 2
 
+// GatewayModeNats connect to DevIoT using NATS protocol
+const GatewayModeNats = 3
+
 type wrapper struct {
 	thing    Thing
 	instance Instance
 }
 
-// NewGateway create a gateway service
+// NewGateway create a gateway service. The transport is selected by opts["mode"]
+// (one of GatewayModeMqtt, GatewayModeNats, GatewayModeHttpPull, GatewayModeHttpPush),
+// defaulting to GatewayModeMqtt, and dispatched through the RegisterConnector registry.
 func NewGateway(name string, deviotServer string, connectorServer string, account string, opts map[string]interface{}) (*Gateway, error) {
+	mode := GatewayModeMqtt
+	if opts != nil {
+		if m, found := opts["mode"]; found {
+			mode = m.(int)
+		}
+	}
+	return NewGatewayWithConnector(name, deviotServer, connectorServer, account, mode, opts)
+}
+
+// NewGatewayWithConnector create a gateway service using the connector registered for mode.
+// See RegisterConnector for how to add or override transports.
+func NewGatewayWithConnector(name string, deviotServer string, connectorServer string, account string, mode int, opts map[string]interface{}) (*Gateway, error) {
 	name = strings.Replace(name, "-", "_", -1)
 
 	gateway := Gateway{
-		Name:         name,
-		Account:      account,
-		Kind:         "device",
-		Mode:         GatewayModeMqtt,
-		Logger:       log.New(os.Stdout, "[DevIoT] ", log.LstdFlags),
-		Things:       make(map[string]wrapper),
-		deviotServer: deviotServer,
+		Name:             name,
+		Account:          account,
+		Kind:             "device",
+		Mode:             mode,
+		Logger:           NewStdLogger(),
+		Things:           make(map[string]wrapper),
+		deviotServer:     deviotServer,
+		opts:             opts,
+		registerInterval: defaultRegisterInterval,
+		httpClient:       &http.Client{Timeout: defaultHTTPTimeout},
+		readyCh:          make(chan struct{}),
 	}
 	if opts != nil {
 		kind, found := opts["kind"]
 		if found {
 			gateway.Kind = kind.(string)
 		}
+		if interval, found := opts["registerInterval"]; found {
+			gateway.registerInterval = interval.(time.Duration)
+		}
+		if timeout, found := opts["httpTimeout"]; found {
+			gateway.httpClient = &http.Client{Timeout: timeout.(time.Duration)}
+		}
+		if logger, found := opts["logger"]; found {
+			gateway.Logger = logger.(Logger)
+		}
+	}
+
+	factory, found := lookupConnector(mode)
+	if !found {
+		return nil, fmt.Errorf("no connector registered for mode %d", mode)
 	}
-	connector, err := NewMqttConnector(&gateway, connectorServer)
+	connector, err := factory(&gateway, connectorServer)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +150,10 @@ func (g *Gateway) Start() error {
 	if err != nil {
 		return err
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.registerCancel = cancel
+
 	go func() {
 		model := make(map[string]interface{})
 		model["name"] = g.Name
@@ -107,68 +168,117 @@ func (g *Gateway) Start() error {
 		first := true
 		for {
 			sensors := make([]interface{}, 0)
-			for _, w := range g.Things {
-				sensors = append(sensors, w.thing)
+			for _, t := range g.Snapshot() {
+				sensors = append(sensors, t)
 			}
 			model["sensors"] = sensors
 			jsonData, _ := json.Marshal(model)
 
 			url := fmt.Sprintf("%s%s", g.deviotServer, "/api/v1/gateways")
-			req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 			req.Header.Set("Content-Type", "application/json")
 
-			client := &http.Client{}
-			resp, err := client.Do(req)
+			resp, err := g.httpClient.Do(req)
 
 			if err != nil {
 				if first || g.IsRegistered() {
-					g.Logger.Printf("Failed to register gateway service %s - %v", g.Name, err)
+					g.Logger.Debug("Failed to register gateway service", "gateway", g.Name, "error", err)
 				}
 				g.setRegistered(false)
 			} else {
 				resp.Body.Close()
 				if resp.StatusCode >= 200 && resp.StatusCode < 400 {
 					if first || !g.IsRegistered() {
-						g.Logger.Printf("Registered gateway service to %s", g.deviotServer)
+						g.Logger.Info("Registered gateway service", "gateway", g.Name, "server", g.deviotServer)
+						g.readyOnce.Do(func() { close(g.readyCh) })
+						if g.OnRegistered != nil {
+							g.OnRegistered()
+						}
 					}
 					g.setRegistered(true)
 				} else {
 					if first || g.IsRegistered() {
-						g.Logger.Printf("Failed to register gateway service %s - %v", g.Name, resp.Status)
+						g.Logger.Debug("Failed to register gateway service", "gateway", g.Name, "status", resp.Status)
 					}
 					g.setRegistered(false)
 				}
 			}
 
 			first = false
-			time.Sleep(1 * time.Minute)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(g.registerInterval):
+			}
 		}
 	}()
-	g.Logger.Printf("gateway service %s started", g.Name)
+	g.Logger.Info("gateway service started", "gateway", g.Name)
 	return err
 }
 
+// Ready blocks until the gateway has successfully registered with the DevIoT
+// server at least once, or ctx expires.
+func (g *Gateway) Ready(ctx context.Context) error {
+	select {
+	case <-g.readyCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Stop gateway service
 func (g *Gateway) Stop() error {
-	g.Logger.Printf("gateway service %s stopped", g.Name)
+	if g.registerCancel != nil {
+		g.registerCancel()
+	}
+	g.Logger.Info("gateway service stopped", "gateway", g.Name)
 	return g.connector.Stop()
 }
 
 // Register a thing to gateway service
 func (g *Gateway) Register(id string, name string, instance Instance) {
+	g.thingsMu.RLock()
+	_, ok := g.Things[id]
+	g.thingsMu.RUnlock()
+	if ok {
+		g.Logger.Warn("thing already registered", "thing", id)
+		return
+	}
+
+	kind := strings.ToLower(reflect.TypeOf(instance).Elem().String())
+	parts := strings.Split(kind, ".")
+	kind = parts[len(parts)-1]
+	t := Thing{Id: id, Name: name, Kind: kind}
+	t.Actions = make([]Action, 0)
+	t.Properties = make([]Property, 0)
+	instance.Init(&t)
+
+	g.thingsMu.Lock()
 	if _, ok := g.Things[id]; ok {
-		g.Logger.Printf("Thing %s has already been registered", id)
-	} else {
-		kind := strings.ToLower(reflect.TypeOf(instance).Elem().String())
-		parts := strings.Split(kind, ".")
-		kind = parts[len(parts)-1]
-		t := Thing{Id: id, Name: name, Kind: kind}
-		t.Actions = make([]Action, 0)
-		t.Properties = make([]Property, 0)
-		instance.Init(&t)
-		g.Things[id] = wrapper{thing: t, instance: instance}
-		g.Logger.Printf("Thing %s.%s(%v) registered", t.Id, t.Name, t.Kind)
+		g.thingsMu.Unlock()
+		g.Logger.Warn("thing already registered", "thing", id)
+		return
+	}
+	g.Things[id] = wrapper{thing: t, instance: instance}
+	g.thingsMu.Unlock()
+
+	g.Logger.Info("thing registered", "thing", t.Id, "name", t.Name, "kind", t.Kind)
+	if g.OnRegister != nil {
+		g.OnRegister(id, t)
+	}
+}
+
+// Snapshot returns a point-in-time copy of the registered things, safe to
+// range over concurrently with Register/Deregister calls from user code.
+func (g *Gateway) Snapshot() []Thing {
+	g.thingsMu.RLock()
+	defer g.thingsMu.RUnlock()
+	things := make([]Thing, 0, len(g.Things))
+	for _, w := range g.Things {
+		things = append(things, w.thing)
 	}
+	return things
 }
 
 // IsRegistered check if gateway service has been registered to DevIoT
@@ -187,18 +297,33 @@ func (g *Gateway) setRegistered(registered bool) {
 
 // Deregister a thing from gateway service
 func (g *Gateway) Deregister(id string) {
-	if w, ok := g.Things[id]; ok {
+	g.thingsMu.Lock()
+	w, ok := g.Things[id]
+	if ok {
 		delete(g.Things, id)
-		t := w.thing
-		g.Logger.Printf("Thing %s.%s(%s) deregistered", id, t.Name, t.Kind)
-	} else {
-		g.Logger.Printf("Thing %s not registered yet", id)
+	}
+	g.thingsMu.Unlock()
+
+	if !ok {
+		g.Logger.Warn("thing not registered yet", "thing", id)
+		return
+	}
+	t := w.thing
+	g.Logger.Info("thing deregistered", "thing", id, "name", t.Name, "kind", t.Kind)
+	if g.OnDeregister != nil {
+		g.OnDeregister(id, t)
 	}
 }
 
 // SendData send data to DevIoT server
 func (g *Gateway) SendData(data map[string]interface{}) error {
-	return g.connector.Publish(data)
+	return g.SendDataContext(context.Background(), data)
+}
+
+// SendDataContext sends data to the DevIoT server, aborting if ctx is done
+// before the connector acknowledges the publish.
+func (g *Gateway) SendDataContext(ctx context.Context, data map[string]interface{}) error {
+	return g.connector.Publish(ctx, data)
 }
 
 // CallAction call an action on thing
@@ -208,35 +333,41 @@ func (g *Gateway) CallAction(data map[string]interface{}) {
 		id, found = data["name"]
 	}
 	if !found {
-		g.Logger.Printf("Illegal message, thing id/name(%v) not available", id)
+		g.Logger.Warn("illegal message, thing id/name not available", "id", id)
 		return
 	}
+	g.thingsMu.RLock()
 	wrapper, found := g.Things[id.(string)]
+	g.thingsMu.RUnlock()
 	if !found {
-		g.Logger.Printf("Illegal message, thing id/name(%v) not available", id)
+		g.Logger.Warn("illegal message, thing id/name not available", "id", id)
 		return
 	}
 
 	action, found := data["action"]
 	if !found {
-		g.Logger.Printf("Illegal message, thing action(%v) not available", action)
+		g.Logger.Warn("illegal message, thing action not available", "action", action)
 		return
 	}
 	actionDef, found := wrapper.thing.FindAction(action.(string))
 	if !found {
-		g.Logger.Printf("Illegal message, thing action(%v) not available", action)
+		g.Logger.Warn("illegal message, thing action not available", "action", action)
 		return
 	}
 
+	if g.OnAction != nil {
+		g.OnAction(id.(string), action.(string), data)
+	}
+
 	method, ok := reflect.TypeOf(wrapper.instance).MethodByName(action.(string))
 	if !ok {
-		g.Logger.Printf("Illegal message, thing method(%v) not available", action)
+		g.Logger.Warn("illegal message, thing method not available", "action", action)
 		return
 	}
 
 	if method.Type.NumIn() != len(actionDef.Parameters)+1 && method.Type.NumIn() != len(actionDef.Parameters)+2 {
-		g.Logger.Printf("Illegal message, thing method(%v) arguments(%d:%d) does not match",
-			action, method.Type.NumIn(), len(actionDef.Parameters))
+		g.Logger.Warn("illegal message, thing method arguments do not match",
+			"action", action, "got", method.Type.NumIn(), "expected", len(actionDef.Parameters))
 		return
 	}
 
@@ -251,7 +382,7 @@ func (g *Gateway) CallAction(data map[string]interface{}) {
 		} else {
 			v, err := convert(param.Type, method.Type.In(i), v)
 			if err != nil {
-				g.Logger.Printf("%v", err)
+				g.Logger.Error("failed to convert action parameter", "error", err)
 				return
 			}
 			args[i] = reflect.ValueOf(v)
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HttpPullConnector implements GatewayModeHttpPull: it long-polls the DevIoT
+// server for pending actions and posts thing data back over plain HTTP.
+type HttpPullConnector struct {
+	gateway *Gateway
+	client  *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewHttpPullConnector create an HTTP long-poll connector
+func NewHttpPullConnector(gateway *Gateway, connectorServer string) (Connector, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HttpPullConnector{
+		gateway: gateway,
+		client:  &http.Client{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Start begins long-polling for actions
+func (c *HttpPullConnector) Start() error {
+	actionURL := fmt.Sprintf("%s/api/v1/gateways/%s/actions", c.gateway.deviotServer, c.gateway.Name)
+	c.gateway.Data = fmt.Sprintf("%s/api/v1/gateways/%s/data", c.gateway.deviotServer, c.gateway.Name)
+	c.gateway.Action = actionURL
+	go c.poll(actionURL)
+	c.gateway.Logger.Info("http pull connector polling", "url", actionURL)
+	return nil
+}
+
+func (c *HttpPullConnector) poll(actionURL string) {
+	for {
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		req, err := http.NewRequestWithContext(c.ctx, "GET", actionURL, nil)
+		if err != nil {
+			c.gateway.Logger.Error("failed to build poll request", "url", actionURL, "error", err)
+			return
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.gateway.Logger.Warn("failed to poll actions", "url", actionURL, "error", err)
+			if !c.sleep(time.Second) {
+				return
+			}
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			c.gateway.Logger.Warn("failed to poll actions", "url", actionURL, "status", resp.Status)
+			if !c.sleep(time.Second) {
+				return
+			}
+			continue
+		}
+
+		var actions []map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&actions)
+		resp.Body.Close()
+		if err != nil {
+			c.gateway.Logger.Warn("failed to decode actions response", "url", actionURL, "error", err)
+			if !c.sleep(time.Second) {
+				return
+			}
+			continue
+		}
+		for _, action := range actions {
+			c.gateway.CallAction(action)
+		}
+	}
+}
+
+// sleep waits for d, or returns false early if the connector is stopped.
+func (c *HttpPullConnector) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// Stop stops the long-poll loop
+func (c *HttpPullConnector) Stop() error {
+	c.cancel()
+	return nil
+}
+
+// Publish posts data to the DevIoT server
+func (c *HttpPullConnector) Publish(ctx context.Context, data map[string]interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.gateway.Data, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// HttpPushOptions configures the HttpPushConnector's callback listener.
+type HttpPushOptions struct {
+	// AuthToken, if set, is required as a bearer token (Authorization: Bearer
+	// <token>) on every incoming action callback; requests without a match
+	// are rejected with 401. Leave unset only when the listen address is
+	// already behind a trusted network or an authenticating reverse proxy -
+	// unlike MqttConnector, the callback listener has no transport-level
+	// authentication of its own.
+	AuthToken string
+}
+
+// HttpPushConnector implements GatewayModeHttpPush: it runs an HTTP server that
+// receives action callbacks from the DevIoT server and forwards them to Gateway.CallAction.
+type HttpPushConnector struct {
+	gateway *Gateway
+	client  *http.Client
+	server  *http.Server
+	options *HttpPushOptions
+}
+
+// NewHttpPushConnector create an HTTP push connector listening on connectorServer
+func NewHttpPushConnector(gateway *Gateway, connectorServer string, options *HttpPushOptions) (Connector, error) {
+	if options == nil {
+		options = &HttpPushOptions{}
+	}
+	return &HttpPushConnector{
+		gateway: gateway,
+		client:  &http.Client{},
+		server:  &http.Server{Addr: connectorServer},
+		options: options,
+	}, nil
+}
+
+// Start starts the callback HTTP server
+func (c *HttpPushConnector) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/actions", c.handleAction)
+	c.server.Handler = mux
+
+	c.gateway.Data = fmt.Sprintf("%s/api/v1/gateways/%s/data", c.gateway.deviotServer, c.gateway.Name)
+	c.gateway.Action = fmt.Sprintf("http://%s/actions", c.server.Addr)
+
+	go func() {
+		if err := c.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			c.gateway.Logger.Error("http push connector stopped", "error", err)
+		}
+	}()
+	c.gateway.Logger.Info("http push connector listening", "addr", c.server.Addr)
+	return nil
+}
+
+func (c *HttpPushConnector) handleAction(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var data map[string]interface{}
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.gateway.CallAction(data)
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized checks the request's bearer token against options.AuthToken.
+// When no AuthToken is configured, every request is allowed - the operator
+// is expected to put the listener behind a trusted network or proxy instead.
+func (c *HttpPushConnector) authorized(r *http.Request) bool {
+	if c.options.AuthToken == "" {
+		return true
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) != len(prefix)+len(c.options.AuthToken) || auth[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(c.options.AuthToken)) == 1
+}
+
+// Stop shuts down the callback HTTP server
+func (c *HttpPushConnector) Stop() error {
+	return c.server.Close()
+}
+
+// Publish posts data to the DevIoT server
+func (c *HttpPushConnector) Publish(ctx context.Context, data map[string]interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.gateway.Data, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
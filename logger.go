@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Logger is the structured, leveled logging interface used throughout the
+// gateway. kv must be an even number of arguments forming key/value pairs
+// (e.g. Info("thing registered", "thing", id, "gateway", name)); a trailing
+// unpaired key is logged with a "MISSING" value. Implementations backed by
+// zap, zerolog or logrus can be injected via opts["logger"] in NewGateway.
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// stdLogger is the default Logger, backed by the standard library logger
+// and preserving the "[DevIoT] " prefixed, timestamped output used before
+// structured logging was introduced.
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// NewStdLogger creates the default stdlib-backed Logger
+func NewStdLogger() Logger {
+	return &stdLogger{logger: log.New(os.Stdout, "[DevIoT] ", log.LstdFlags)}
+}
+
+func (l *stdLogger) Debug(msg string, kv ...interface{}) { l.log("DEBUG", msg, kv...) }
+func (l *stdLogger) Info(msg string, kv ...interface{})  { l.log("INFO", msg, kv...) }
+func (l *stdLogger) Warn(msg string, kv ...interface{})  { l.log("WARN", msg, kv...) }
+func (l *stdLogger) Error(msg string, kv ...interface{}) { l.log("ERROR", msg, kv...) }
+
+func (l *stdLogger) log(level string, msg string, kv ...interface{}) {
+	l.logger.Printf("%s %s%s", level, msg, formatFields(kv))
+}
+
+func formatFields(kv []interface{}) string {
+	if len(kv) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(kv); i += 2 {
+		key := kv[i]
+		var value interface{} = "MISSING"
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", key, value)
+	}
+	return b.String()
+}
+
+// pahoLogAdapter lets the Logger interface satisfy paho's mqtt.Logger
+// (Println/Printf) so MqttConnector can route paho's own error/critical
+// logs through the gateway's configured Logger.
+type pahoLogAdapter struct {
+	logger Logger
+}
+
+func (a *pahoLogAdapter) Println(v ...interface{}) {
+	a.logger.Error(strings.TrimSpace(fmt.Sprintln(v...)))
+}
+
+func (a *pahoLogAdapter) Printf(format string, v ...interface{}) {
+	a.logger.Error(fmt.Sprintf(format, v...))
+}
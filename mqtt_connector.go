@@ -1,13 +1,18 @@
 package gateway
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // MqttConnector represents mqtt based connector
@@ -15,18 +20,64 @@ type MqttConnector struct {
 	DataTopic       string
 	ActionTopic     string
 	connectorServer string
+	options         *MqttOptions
 	client          mqtt.Client
 	gateway         *Gateway
 }
 
+// MqttOptions holds the paho client knobs needed to run MQTT in production:
+// authentication, TLS, timeouts, QoS and a Last-Will-and-Testament message.
+type MqttOptions struct {
+	// Username and Password authenticate with the broker, if required.
+	Username string
+	Password string
+
+	// ClientID overrides the client id derived from the gateway name.
+	ClientID string
+
+	// ClientCertFile/ClientKeyFile are used for mutual TLS. CACertFile, if
+	// set, is used to validate the broker certificate instead of the system
+	// root pool. InsecureSkipVerify disables certificate validation and
+	// should only be used in development.
+	ClientCertFile     string
+	ClientKeyFile      string
+	CACertFile         string
+	InsecureSkipVerify bool
+
+	// KeepAlive, PingTimeout and ConnectTimeout tune the underlying paho
+	// client. Zero values fall back to the paho defaults.
+	KeepAlive      time.Duration
+	PingTimeout    time.Duration
+	ConnectTimeout time.Duration
+
+	// PublishQoS and SubscribeQoS control the QoS level used for the data
+	// topic and the action topic respectively. Both default to 0.
+	PublishQoS   byte
+	SubscribeQoS byte
+
+	// WillTopic, WillPayload, WillQoS and WillRetained configure a
+	// Last-Will-and-Testament message the broker delivers if the gateway
+	// disconnects ungracefully. WillTopic is required to enable the will.
+	WillTopic    string
+	WillPayload  string
+	WillQoS      byte
+	WillRetained bool
+}
+
 const defaultPort = 1883
+const defaultTLSPort = 8883
+const defaultWSSPort = 443
 
 // NewMqttConnector create a mqtt based connector
-func NewMqttConnector(gateway *Gateway, connectorServer string) (*MqttConnector, error) {
+func NewMqttConnector(gateway *Gateway, connectorServer string, options *MqttOptions) (*MqttConnector, error) {
 	u, err := url.Parse(connectorServer)
 	if err != nil {
 		return nil, err
 	}
+	if options == nil {
+		options = &MqttOptions{}
+	}
+
 	host, portStr, _ := net.SplitHostPort(u.Host)
 	var port int
 	if portStr != "" {
@@ -35,8 +86,15 @@ func NewMqttConnector(gateway *Gateway, connectorServer string) (*MqttConnector,
 			return nil, err
 		}
 	} else {
-		port = defaultPort
 		host = u.Host
+		switch {
+		case u.Scheme == "wss":
+			port = defaultWSSPort
+		case isTLSScheme(u.Scheme):
+			port = defaultTLSPort
+		default:
+			port = defaultPort
+		}
 	}
 
 	ns := strings.Replace(gateway.Account, "@", "", -1)
@@ -53,36 +111,107 @@ func NewMqttConnector(gateway *Gateway, connectorServer string) (*MqttConnector,
 	gateway.Data = dataTopic
 	gateway.Action = actionTopic
 
-	mqtt.ERROR = gateway.Logger
-	mqtt.CRITICAL = gateway.Logger
+	mqtt.ERROR = &pahoLogAdapter{logger: gateway.Logger}
+	mqtt.CRITICAL = &pahoLogAdapter{logger: gateway.Logger}
 
 	return &MqttConnector{
 		DataTopic:       dataTopic,
 		ActionTopic:     actionTopic,
 		connectorServer: connectorServer,
+		options:         options,
 		gateway:         gateway,
 	}, nil
 }
 
+// isTLSScheme reports whether scheme requires a TLS connection to the broker.
+func isTLSScheme(scheme string) bool {
+	return scheme == "ssl" || scheme == "tls" || scheme == "wss" || scheme == "mqtts"
+}
+
 // Start mqtt connector
 func (c *MqttConnector) Start() error {
+	clientID := c.gateway.Name
+	if c.options.ClientID != "" {
+		clientID = c.options.ClientID
+	}
+
 	opts := mqtt.NewClientOptions().AddBroker(c.connectorServer).
-		SetClientID(c.gateway.Name).
+		SetClientID(clientID).
 		SetAutoReconnect(true).
 		SetCleanSession(false)
+
+	if c.options.Username != "" {
+		opts.SetUsername(c.options.Username)
+	}
+	if c.options.Password != "" {
+		opts.SetPassword(c.options.Password)
+	}
+	if c.options.KeepAlive > 0 {
+		opts.SetKeepAlive(c.options.KeepAlive)
+	}
+	if c.options.PingTimeout > 0 {
+		opts.SetPingTimeout(c.options.PingTimeout)
+	}
+	if c.options.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(c.options.ConnectTimeout)
+	}
+	if c.options.WillTopic != "" {
+		opts.SetWill(c.options.WillTopic, c.options.WillPayload, c.options.WillQoS, c.options.WillRetained)
+	}
+
+	tlsConfig, err := c.options.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
 	opts.OnConnect = func(client mqtt.Client) {
-		if token := c.client.Subscribe(c.ActionTopic, 0, c.onMessage); token.Wait() && token.Error() != nil {
-			c.gateway.Logger.Printf("Failed to subscribe to topic %s - %v", c.ActionTopic, token.Error())
+		if token := c.client.Subscribe(c.ActionTopic, c.options.SubscribeQoS, c.onMessage); token.Wait() && token.Error() != nil {
+			c.gateway.Logger.Warn("failed to subscribe to topic", "topic", c.ActionTopic, "error", token.Error())
 		}
 	}
 	c.client = mqtt.NewClient(opts)
 	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
 		return token.Error()
 	}
-	c.gateway.Logger.Printf("mqtt server %s connected", c.connectorServer)
+	c.gateway.Logger.Info("mqtt server connected", "server", c.connectorServer)
 	return nil
 }
 
+// tlsConfig builds a *tls.Config from the configured certificates, or nil if
+// no TLS material was provided and InsecureSkipVerify wasn't requested.
+func (o *MqttOptions) tlsConfig() (*tls.Config, error) {
+	if o.ClientCertFile == "" && o.CACertFile == "" && !o.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate - %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.CACertFile != "" {
+		caCert, err := ioutil.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate - %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", o.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
 // Stop mqtt connector
 func (c *MqttConnector) Stop() error {
 	c.client.Disconnect(250)
@@ -90,21 +219,31 @@ func (c *MqttConnector) Stop() error {
 }
 
 // Publish to DevioT server
-func (c *MqttConnector) Publish(data map[string]interface{}) error {
+func (c *MqttConnector) Publish(ctx context.Context, data map[string]interface{}) error {
 	if c.client != nil && c.client.IsConnected() {
 		jsonData, _ := json.Marshal(data)
-		token := c.client.Publish(c.DataTopic, 0, false, string(jsonData))
-		token.Wait()
-		return token.Error()
+		token := c.client.Publish(c.DataTopic, c.options.PublishQoS, false, string(jsonData))
+		return waitForToken(ctx, token)
 	}
 	return nil
 }
 
+// waitForToken blocks until token completes or ctx is done, whichever comes
+// first, so a slow or wedged broker can't hang a caller's publish forever.
+func waitForToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (c *MqttConnector) onMessage(client mqtt.Client, msg mqtt.Message) {
 	var data map[string]interface{}
 	err := json.Unmarshal(msg.Payload(), &data)
 	if err != nil {
-		c.gateway.Logger.Printf("Failed to unmarshal message %s - %v", string(msg.Payload()), err)
+		c.gateway.Logger.Error("failed to unmarshal message", "payload", string(msg.Payload()), "error", err)
 	} else {
 		c.gateway.CallAction(data)
 	}
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	nats "github.com/nats-io/nats.go"
+	"strings"
+	"time"
+)
+
+// NatsConnector represents nats based connector
+type NatsConnector struct {
+	DataSubject   string
+	ActionSubject string
+	natsServer    string
+	options       *NatsOptions
+	conn          *nats.Conn
+	sub           *nats.Subscription
+	gateway       *Gateway
+}
+
+// NatsOptions holds the nats.Connect knobs needed to run against a
+// production NATS cluster: authentication and reconnect behavior.
+type NatsOptions struct {
+	// Username/Password and Token authenticate with the server. Token takes
+	// precedence over Username/Password when both are set.
+	Username string
+	Password string
+	Token    string
+
+	// MaxReconnects and ReconnectWait mirror the nats.go client options of
+	// the same name. Zero values fall back to the nats.go defaults.
+	MaxReconnects int
+	ReconnectWait time.Duration
+}
+
+// NewNatsConnector create a nats based connector
+func NewNatsConnector(gateway *Gateway, natsServer string, options *NatsOptions) (*NatsConnector, error) {
+	if options == nil {
+		options = &NatsOptions{}
+	}
+
+	ns := strings.Replace(gateway.Account, "@", "", -1)
+	if ns == "" {
+		ns = "_"
+	}
+
+	dataSubject := fmt.Sprintf("deviot.%s.%s.data", ns, gateway.Name)
+	actionSubject := fmt.Sprintf("deviot.%s.%s.action", ns, gateway.Name)
+
+	gateway.Mode = GatewayModeNats
+	gateway.Data = dataSubject
+	gateway.Action = actionSubject
+
+	return &NatsConnector{
+		DataSubject:   dataSubject,
+		ActionSubject: actionSubject,
+		natsServer:    natsServer,
+		options:       options,
+		gateway:       gateway,
+	}, nil
+}
+
+// Start nats connector
+func (c *NatsConnector) Start() error {
+	opts := []nats.Option{
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			c.gateway.Logger.Info("nats server reconnected", "server", conn.ConnectedUrl())
+		}),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			if err != nil {
+				c.gateway.Logger.Warn("nats server disconnected", "server", c.natsServer, "error", err)
+			}
+		}),
+	}
+	if c.options.MaxReconnects != 0 {
+		opts = append(opts, nats.MaxReconnects(c.options.MaxReconnects))
+	}
+	if c.options.ReconnectWait > 0 {
+		opts = append(opts, nats.ReconnectWait(c.options.ReconnectWait))
+	}
+	if c.options.Token != "" {
+		opts = append(opts, nats.Token(c.options.Token))
+	} else if c.options.Username != "" {
+		opts = append(opts, nats.UserInfo(c.options.Username, c.options.Password))
+	}
+
+	conn, err := nats.Connect(c.natsServer, opts...)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+
+	sub, err := conn.Subscribe(c.ActionSubject, c.onMessage)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c.sub = sub
+
+	c.gateway.Logger.Info("nats server connected", "server", c.natsServer)
+	return nil
+}
+
+// Stop nats connector
+func (c *NatsConnector) Stop() error {
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// Publish to DevioT server
+func (c *NatsConnector) Publish(ctx context.Context, data map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.conn == nil || !c.conn.IsConnected() {
+		return nil
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.conn.Publish(c.DataSubject, jsonData)
+}
+
+func (c *NatsConnector) onMessage(msg *nats.Msg) {
+	var data map[string]interface{}
+	err := json.Unmarshal(msg.Data, &data)
+	if err != nil {
+		c.gateway.Logger.Error("failed to unmarshal message", "payload", string(msg.Data), "error", err)
+	} else {
+		c.gateway.CallAction(data)
+	}
+}